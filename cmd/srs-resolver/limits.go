@@ -0,0 +1,98 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// ErrRequestTooLarge is returned when a request declares a size larger
+// than max_request_bytes before any of its payload is read, e.g. a
+// socketmap netstring's length prefix.
+var ErrRequestTooLarge = errors.New("request too large")
+
+// netstringFramingOverhead is how much room beyond max_request_bytes the
+// socketmap listener's limitedReader allows for netstring framing (the
+// decimal length prefix, its trailing ":", and the closing ","), so that
+// max_request_bytes bounds the "name key" payload operators actually
+// configure rather than the whole wire encoding. 20 digits covers any
+// int64 length prefix with room to spare.
+const netstringFramingOverhead = 22
+
+// limitedReader wraps a reader and tracks whether it was ever asked to
+// read past its limit, so callers can tell "client hung up early" apart
+// from "client sent more than max_request_bytes".
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func newLimitedReader(r io.Reader, limit int64) *limitedReader {
+	return &limitedReader{r: r, remaining: limit}
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.remaining <= 0 {
+		lr.exceeded = true
+		return 0, io.EOF
+	}
+	if int64(len(p)) > lr.remaining {
+		p = p[:lr.remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+	return n, err
+}
+
+// classifyReadError tells apart the three ways reading a request can fail:
+// the read deadline expired, max_request_bytes was exceeded, or something
+// else (e.g. the client just closed the connection).
+func classifyReadError(err error, exceeded bool) string {
+	if exceeded || errors.Is(err, ErrRequestTooLarge) {
+		return "request too large"
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return "timeout"
+	}
+	return "read error"
+}
+
+// writeReadError replies to a simple-protocol client with the 500 line
+// matching classifyReadError's verdict.
+func writeReadError(conn net.Conn, err error, exceeded bool) {
+	fmt.Fprintf(conn, "500 %s\n", classifyReadError(err, exceeded))
+}
+
+// writeSocketmapReadError replies to a socketmap client with a netstring
+// matching classifyReadError's verdict: TEMP for conditions a retry might
+// clear, PERM for a request that will never fit.
+func writeSocketmapReadError(conn net.Conn, err error, exceeded bool) {
+	reason := classifyReadError(err, exceeded)
+	if exceeded || errors.Is(err, ErrRequestTooLarge) {
+		writeNetstring(conn, "PERM "+reason)
+		return
+	}
+	writeNetstring(conn, "TEMP "+reason)
+}
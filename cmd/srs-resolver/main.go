@@ -21,18 +21,28 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"net"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	// For dropping privileges
 	"os/user"
 	"strconv"
 	"syscall"
 
+	"github.com/dszlage/srs-resolver/internal/activation"
+	"github.com/dszlage/srs-resolver/internal/logger"
+	"github.com/dszlage/srs-resolver/internal/metrics"
+	"github.com/dszlage/srs-resolver/internal/sdnotify"
+
 	// For TOML parsing
 	"github.com/BurntSushi/toml"
 )
@@ -43,27 +53,95 @@ var (
 	showVersionLong = flag.Bool("version", false, "Show info")
 )
 
-// LogLevel type and constants
-type LogLevel int
-
 const version = "1.0.0"
 const notAllowedChars = " <>(),;=\"" // Characters not allowed in a clean email address
-const (
-	LogError LogLevel = iota
-	LogInfo
-	LogDebug
-)
 
-var currentLogLevel = LogError
+// log is the process-wide structured logger, set up by InitLogging.
+var log *logger.Logger
 
 // Config struct for TOML parsing
 type Config struct {
-	Listen          string `toml:"listen"`
-	LogFile         string `toml:"log_file"`
-	LogLevel        string `toml:"log_level"`
-	FallbackAddress string `toml:"fallback_address"`
-	DropUser        string `toml:"drop_user"`
-	DropGroup       string `toml:"drop_group"`
+	Listen          string   `toml:"listen"`
+	LogFile         string   `toml:"log_file"`
+	LogLevel        string   `toml:"log_level"`
+	FallbackAddress string   `toml:"fallback_address"`
+	DropUser        string   `toml:"drop_user"`
+	DropGroup       string   `toml:"drop_group"`
+	SrsSecrets      []string `toml:"srs_secrets"`
+	MaxAgeDays      int      `toml:"max_age_days"`
+	Protocol        string   `toml:"protocol"`
+	LogBackend      string   `toml:"log_backend"`
+	SyslogFacility  string   `toml:"syslog_facility"`
+	MetricsListen   string   `toml:"metrics_listen"`
+	MaxConcurrent   int      `toml:"max_concurrent"`
+	ReadTimeout     string   `toml:"read_timeout"`
+	WriteTimeout    string   `toml:"write_timeout"`
+	MaxRequestBytes int64    `toml:"max_request_bytes"`
+}
+
+// isSocketmap reports whether the configured protocol is Postfix's
+// socketmap wire format rather than the default simple get/200 protocol.
+func (c *Config) isSocketmap() bool {
+	return strings.EqualFold(c.Protocol, "socketmap")
+}
+
+// defaultMaxAgeDays is used when max_age_days is unset or non-positive in
+// the config, matching the SRS default of rejecting anything older than
+// three weeks.
+const defaultMaxAgeDays = 21
+
+// maxAgeDays returns the configured SRS timestamp window, falling back to
+// defaultMaxAgeDays when unset.
+func (c *Config) maxAgeDays() int {
+	if c.MaxAgeDays <= 0 {
+		return defaultMaxAgeDays
+	}
+	return c.MaxAgeDays
+}
+
+// Defaults for the tcp_table hardening knobs, used whenever the
+// corresponding TOML key is unset or invalid.
+const (
+	defaultMaxConcurrent   = 256
+	defaultReadTimeout     = 5 * time.Second
+	defaultWriteTimeout    = 5 * time.Second
+	defaultMaxRequestBytes = 1024
+)
+
+// maxConcurrent returns the configured connection concurrency limit,
+// falling back to defaultMaxConcurrent when unset.
+func (c *Config) maxConcurrent() int {
+	if c.MaxConcurrent <= 0 {
+		return defaultMaxConcurrent
+	}
+	return c.MaxConcurrent
+}
+
+// readTimeout returns the configured per-connection read deadline,
+// falling back to defaultReadTimeout when unset or invalid.
+func (c *Config) readTimeout() time.Duration {
+	if d, err := time.ParseDuration(c.ReadTimeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultReadTimeout
+}
+
+// writeTimeout returns the configured per-connection write deadline,
+// falling back to defaultWriteTimeout when unset or invalid.
+func (c *Config) writeTimeout() time.Duration {
+	if d, err := time.ParseDuration(c.WriteTimeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultWriteTimeout
+}
+
+// maxRequestBytes returns the configured request size limit, falling back
+// to defaultMaxRequestBytes when unset.
+func (c *Config) maxRequestBytes() int64 {
+	if c.MaxRequestBytes <= 0 {
+		return defaultMaxRequestBytes
+	}
+	return c.MaxRequestBytes
 }
 
 // LoadConfig loads TOML config from file
@@ -75,54 +153,45 @@ func LoadConfig(path string) (*Config, error) {
 	return &config, nil
 }
 
-// InitLogging sets log output and level
+// InitLogging builds the process-wide logger from cfg, picking its sink
+// (file, syslog, or journald) and level.
 func InitLogging(cfg *Config) error {
-	// Set log level
-	switch strings.ToLower(cfg.LogLevel) {
-	case "debug":
-		currentLogLevel = LogDebug
-	case "info":
-		currentLogLevel = LogInfo
-	case "error":
-		currentLogLevel = LogError
-	default:
-		currentLogLevel = LogError
-	}
-
-	// Set log file output
-	if cfg.LogFile != "" && !*logToStdOut {
-		f, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return err
-		}
-		log.SetOutput(f)
-	} else if *logToStdOut {
-		log.SetOutput(os.Stdout)
+	logCfg := logger.Config{
+		Backend:  cfg.LogBackend,
+		FilePath: cfg.LogFile,
+		Facility: cfg.SyslogFacility,
+		Level:    logger.ParseLevel(cfg.LogLevel),
+	}
+	if *logToStdOut {
+		logCfg.Backend = "file"
+		logCfg.FilePath = "/dev/stdout"
 	}
 
-	log.SetFlags(log.LstdFlags)
+	l, err := logger.New(logCfg)
+	if err != nil {
+		return err
+	}
+	log = l
 	return nil
 }
 
-// / Logging helpers
-func logFatal(format string, a ...any) {
-	log.Fatalf("[FATAL] "+format, a...)
-}
-func logError(format string, a ...any) {
-	log.Printf("[ERROR] "+format, a...)
-}
+// configPath is the fixed location of the TOML config, also re-read on
+// SIGHUP.
+const configPath = "/etc/srs-resolver/srs-resolver.conf"
 
-func logInfo(format string, a ...any) {
-	if currentLogLevel >= LogInfo {
-		log.Printf("[INFO] "+format, a...)
-	}
-}
+// shutdownTimeout bounds how long we wait for in-flight handle()
+// goroutines to finish after a SIGTERM/SIGINT before exiting anyway.
+const shutdownTimeout = 10 * time.Second
 
-func logDebug(format string, a ...any) {
-	if currentLogLevel >= LogDebug {
-		log.Printf("[DEBUG] "+format, a...)
-	}
-}
+// liveConfig holds the active *Config, swapped out by a SIGHUP reload
+// while the accept loop keeps running against the same listener.
+var liveConfig atomic.Pointer[Config]
+
+// liveSem holds the connection-concurrency semaphore, sized from
+// liveConfig's max_concurrent and rebuilt by reloadConfig when that value
+// changes, so a SIGHUP actually changes how many connections run at once
+// rather than only taking effect on the next restart.
+var liveSem atomic.Pointer[chan struct{}]
 
 func main() {
 	flag.Parse()
@@ -133,8 +202,7 @@ func main() {
 			"\nLicense: GNU General Public License v3 or later")
 	}
 
-	cfg, err := LoadConfig("/etc/srs-resolver/srs-resolver.conf")
-
+	cfg, err := LoadConfig(configPath)
 	if err != nil {
 		fmt.Println("[FATAL] Config error: ", err)
 		os.Exit(1)
@@ -144,37 +212,190 @@ func main() {
 		fmt.Println("[FATAL] Logging error: ", err)
 		os.Exit(1)
 	}
+	liveConfig.Store(cfg)
 
-	logInfo("srs-resolver version %s starting...", version)
+	log.Info("starting", logger.F("version", version))
 
 	// Drop privileges if configured
 	if err := dropPrivileges(cfg.DropUser, cfg.DropGroup); err != nil {
-		logFatal("Dropping privileges failed: %v", err)
+		log.Fatal("dropping privileges failed", logger.F("error", err))
+	}
+
+	ln, err := acquireListener(cfg)
+	if err != nil {
+		log.Fatal("listen failed", logger.F("error", err))
+	}
+	log.Info("listening", logger.F("listen", cfg.Listen))
+
+	if err := sdnotify.Notify("READY=1"); err != nil {
+		log.Error("sd_notify READY failed", logger.F("error", err))
+	}
+	if interval, ok := sdnotify.WatchdogInterval(); ok {
+		go watchdogLoop(interval)
+	}
+
+	startMetricsServer(cfg)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cfg.maxConcurrent())
+	liveSem.Store(&sem)
+	go acceptLoop(ln, &wg)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			reloadConfig()
+			continue
+		}
+		log.Info("shutting down", logger.F("signal", sig.String()))
+		_ = sdnotify.Notify("STOPPING=1")
+		ln.Close()
+		waitForDrain(&wg)
+		return
 	}
+}
 
-	ln, err := net.Listen("tcp", cfg.Listen)
+// acquireListener adopts a systemd socket-activated listener when present,
+// otherwise opens cfg.Listen itself.
+func acquireListener(cfg *Config) (net.Listener, error) {
+	listeners, err := activation.Listeners()
 	if err != nil {
-		logFatal("Listen error: %v", err)
+		return nil, err
+	}
+	if len(listeners) > 0 {
+		log.Info("adopted systemd socket-activated listener", logger.F("count", len(listeners)))
+		return listeners[0], nil
 	}
-	logInfo("Listening on %s", cfg.Listen)
+	return net.Listen("tcp", cfg.Listen)
+}
 
+// acceptLoop accepts connections against ln until it is closed (during
+// shutdown), dispatching each to the configured protocol handler and
+// tracking it in wg so shutdown can wait for in-flight handlers to finish.
+// liveSem bounds how many handlers run concurrently; a connection arriving
+// with it full is rejected immediately with "too busy" rather than
+// queuing, so a burst of slow clients can't exhaust file descriptors. It
+// is re-read on every connection so a reloadConfig resize takes effect
+// immediately.
+func acceptLoop(ln net.Listener, wg *sync.WaitGroup) {
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			logError("Connection error: %v", err)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Error("accept failed", logger.F("error", err))
 			continue
 		}
-		go handle(conn, cfg)
+		cfg := liveConfig.Load()
+		sem := *liveSem.Load()
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			log.Error("too busy, rejecting connection", logger.F("remote", conn.RemoteAddr().String()))
+			appMetrics.IncResult(metrics.ResultInvalid)
+			rejectTooBusy(conn, cfg)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if cfg.isSocketmap() {
+				handleSocketmap(conn, cfg)
+			} else {
+				handle(conn, cfg)
+			}
+		}()
+	}
+}
+
+// rejectTooBusy immediately closes a connection that arrived while we're
+// already at max_concurrent, replying in whichever protocol cfg speaks.
+func rejectTooBusy(conn net.Conn, cfg *Config) {
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(cfg.writeTimeout()))
+	if cfg.isSocketmap() {
+		writeNetstring(conn, "TEMP too busy")
+	} else {
+		fmt.Fprintf(conn, "500 too busy\n")
+	}
+}
+
+// waitForDrain waits for wg up to shutdownTimeout before giving up and
+// letting the process exit with connections still in flight.
+func waitForDrain(wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	select {
+	case <-done:
+		log.Info("all connections drained")
+	case <-ctx.Done():
+		log.Error("shutdown timeout exceeded, exiting with connections still in flight")
+	}
+}
+
+// reloadConfig re-reads the TOML config and reopens the log file (or
+// switches sinks) without dropping the listening socket, in response to
+// SIGHUP.
+func reloadConfig() {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		log.Error("reload failed, keeping previous config", logger.F("error", err))
+		return
+	}
+
+	oldLog := log
+	if err := InitLogging(cfg); err != nil {
+		log.Error("reload: reopening log failed, keeping previous config", logger.F("error", err))
+		return
+	}
+	oldLog.Close()
+
+	if newCap := cfg.maxConcurrent(); newCap != cap(*liveSem.Load()) {
+		newSem := make(chan struct{}, newCap)
+		liveSem.Store(&newSem)
+		log.Info("resized connection semaphore", logger.F("max_concurrent", newCap))
+	}
+
+	liveConfig.Store(cfg)
+	log.Info("config reloaded")
+}
+
+// watchdogLoop pings systemd's watchdog at interval until the process
+// exits, so a unit with WatchdogSec= set can detect a hung resolver.
+func watchdogLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := sdnotify.Notify("WATCHDOG=1"); err != nil {
+			log.Error("sd_notify WATCHDOG failed", logger.F("error", err))
+		}
 	}
 }
 
 func handle(conn net.Conn, cfg *Config) {
+	appMetrics.ConnOpened()
+	defer appMetrics.ConnClosed()
 	defer conn.Close()
-	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(cfg.readTimeout()))
+	conn.SetWriteDeadline(time.Now().Add(cfg.writeTimeout()))
+
+	lr := newLimitedReader(conn, cfg.maxRequestBytes())
+	reader := bufio.NewReader(lr)
 
 	line, err := reader.ReadString('\n')
 	if err != nil {
-		fmt.Fprintf(conn, "500 read error\n")
+		writeReadError(conn, err, lr.exceeded)
 		return
 	}
 
@@ -185,67 +406,58 @@ func handle(conn net.Conn, cfg *Config) {
 	}
 
 	address := strings.TrimSpace(line[4:])
+	remote := conn.RemoteAddr().String()
+	start := time.Now()
 
 	// Fast validation - if not SRS, check if it's a clean email
 	// We skip full validation for best performance
 	if !strings.HasPrefix(address, "SRS0=") && !strings.HasPrefix(address, "SRS1=") {
+		appMetrics.ObserveDecode(time.Since(start))
 		if isCleanEmail(address) {
-			logDebug("Address: %s, no decoding required", address)
+			log.Debug("no decoding required", logger.F("addr", address), logger.F("remote", remote))
+			recordTrace(address, address, nil, time.Since(start))
+			appMetrics.IncResult(metrics.ResultOK)
 			fmt.Fprintf(conn, "200 %s\n", address)
 			return
 		}
 		// Fallback or error
 		if cfg.FallbackAddress != "" {
-			logError("Invalid address: %s, returning fallback_address: %s", address, cfg.FallbackAddress)
+			log.Error("invalid address, using fallback", logger.F("addr", address), logger.F("remote", remote), logger.F("fallback", cfg.FallbackAddress))
+			recordTrace(address, cfg.FallbackAddress, nil, time.Since(start))
+			appMetrics.IncResult(metrics.ResultFallback)
 			fmt.Fprintf(conn, "200 %s\n", cfg.FallbackAddress)
 		} else {
-			logError("Invalid address: %s, no fallback_address set, 500 invalid request", address)
+			log.Error("invalid address, no fallback configured", logger.F("addr", address), logger.F("remote", remote))
+			recordTrace(address, "", fmt.Errorf("invalid address"), time.Since(start))
+			appMetrics.IncResult(metrics.ResultInvalid)
 			fmt.Fprintf(conn, "500 invalid request\n")
 		}
 		return
 	}
 
 	// It's SRS, try to decode
-	decoded, err := decodeSRS(address)
+	decoded, hops, err := decodeSRS(address, cfg.SrsSecrets, cfg.maxAgeDays())
+	appMetrics.ObserveDecode(time.Since(start))
 	if err != nil {
+		appMetrics.IncResult(classifySRSError(err))
 		if cfg.FallbackAddress != "" {
-			logError("Invalid SRS: %s (%v), returning fallback_address: %s", address, err, cfg.FallbackAddress)
+			log.Error("invalid SRS, using fallback", logger.F("addr", address), logger.F("remote", remote), logger.F("error", err), logger.F("fallback", cfg.FallbackAddress))
+			recordTrace(address, cfg.FallbackAddress, err, time.Since(start))
 			fmt.Fprintf(conn, "200 %s\n", cfg.FallbackAddress)
 		} else {
-			logError("Invalid SRS: %s (%v), no fallback_address set, 500 invalid request", address, err)
+			log.Error("invalid SRS, no fallback configured", logger.F("addr", address), logger.F("remote", remote), logger.F("error", err))
+			recordTrace(address, "", err, time.Since(start))
 			fmt.Fprintf(conn, "500 invalid request\n")
 		}
 	} else {
-		logInfo("Resolved: %s → %s", address, decoded)
+		appMetrics.ObserveHops(hops)
+		appMetrics.IncResult(metrics.ResultOK)
+		log.Info("resolved", logger.F("addr", address), logger.F("decoded", decoded), logger.F("remote", remote))
+		recordTrace(address, decoded, nil, time.Since(start))
 		fmt.Fprintf(conn, "200 %s\n", decoded)
 	}
 }
 
-func decodeSRS(srs string) (string, error) {
-
-	// SRS0=hash=time=domain=full_local_part@something
-	// SRS1=hash=time=domain=full_local_part@something
-	parts := strings.SplitN(srs, "=", 5)
-	if len(parts) != 5 {
-		return "", fmt.Errorf("SRS format - wrong number of parts")
-	}
-
-	// parts[3] = original domain
-	// parts[4] = local part (which may contain @forwarder)
-	domain := parts[3]
-	local := parts[4]
-
-	// If it already looks like a full address, just switch the domain
-	if strings.Contains(local, "@") {
-		// e.g. user@forwarder.com → user@domain.com
-		user := strings.Split(local, "@")[0]
-		return fmt.Sprintf("%s@%s", user, domain), nil
-	}
-
-	// Normal case
-	return fmt.Sprintf("%s@%s", local, domain), nil
-}
-
 func isCleanEmail(s string) bool {
 	// RFC 5321, 5322 Not allowed characters
 	if strings.ContainsAny(s, notAllowedChars) {
@@ -317,11 +529,13 @@ func dropPrivileges(targetUser string, targetGroup string) error {
 	}
 
 	if newUser.Username == "root" {
-		logError("Warning! Running as root user! Not dropping privileges! Be careful!")
+		log.Error("running as root user, not dropping privileges, be careful")
 	}
 	if newGroup.Name == "root" {
-		logError("Warning! Running as root group! Not dropping privileges! Be careful!")
+		log.Error("running as root group, not dropping privileges, be careful")
 	}
-	logInfo("Running as user: %s (UID %s), group: %s (GID %s)", newUser.Username, newUser.Uid, newGroup.Name, newGroup.Gid)
+	log.Info("running as",
+		logger.F("user", newUser.Username), logger.F("uid", newUser.Uid),
+		logger.F("group", newGroup.Name), logger.F("gid", newGroup.Gid))
 	return nil
 }
@@ -0,0 +1,90 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dszlage/srs-resolver/internal/logger"
+	"github.com/dszlage/srs-resolver/internal/metrics"
+	"github.com/dszlage/srs-resolver/internal/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// traceRingSize is how many recent requests /debug/traces keeps around.
+const traceRingSize = 200
+
+// appMetrics and traces are process-wide: every handle()/handleSocketmap()
+// call instruments the same collectors and ring buffer.
+var (
+	appMetrics = metrics.New(prometheus.DefaultRegisterer)
+	traces     = trace.NewRing(traceRingSize)
+)
+
+// startMetricsServer launches the optional HTTP server exposing
+// /metrics (Prometheus) and /debug/traces (recent request trace JSON),
+// when metrics_listen is configured.
+func startMetricsServer(cfg *Config) {
+	if cfg.MetricsListen == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/debug/traces", serveTraces)
+
+	go func() {
+		if err := http.ListenAndServe(cfg.MetricsListen, mux); err != nil {
+			log.Error("metrics server failed", logger.F("error", err))
+		}
+	}()
+	log.Info("metrics server listening", logger.F("listen", cfg.MetricsListen))
+}
+
+func serveTraces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(traces.Snapshot())
+}
+
+// classifySRSError maps a decodeSRS error to the metrics.Result that best
+// describes it, for srs_resolver_requests_total.
+func classifySRSError(err error) metrics.Result {
+	switch {
+	case errors.Is(err, ErrSRSBadHMAC):
+		return metrics.ResultSRSBadHMAC
+	case errors.Is(err, ErrSRSExpired):
+		return metrics.ResultSRSExpired
+	default:
+		return metrics.ResultInvalid
+	}
+}
+
+// recordTrace appends one request to the /debug/traces ring buffer.
+func recordTrace(input, output string, err error, d time.Duration) {
+	entry := trace.Entry{Time: time.Now(), Input: input, Output: output, Duration: d}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	traces.Add(entry)
+}
@@ -0,0 +1,150 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dszlage/srs-resolver/internal/logger"
+	"github.com/dszlage/srs-resolver/internal/metrics"
+)
+
+// readNetstring reads one netstring ("<len>:<data>,") from r and returns
+// its payload. The declared length is checked against maxLen before buf is
+// allocated, so a connection can't claim an arbitrarily large payload and
+// force a huge allocation before the size-limited reader ever sees a byte
+// of it.
+func readNetstring(r *bufio.Reader, maxLen int64) (string, error) {
+	lenStr, err := r.ReadString(':')
+	if err != nil {
+		return "", fmt.Errorf("netstring length: %w", err)
+	}
+	lenStr = strings.TrimSuffix(lenStr, ":")
+	n, err := strconv.Atoi(lenStr)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("netstring length: invalid %q", lenStr)
+	}
+	if int64(n) > maxLen {
+		return "", fmt.Errorf("netstring length %d: %w", n, ErrRequestTooLarge)
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", fmt.Errorf("netstring payload: %w", err)
+	}
+
+	comma, err := r.ReadByte()
+	if err != nil || comma != ',' {
+		return "", fmt.Errorf("netstring: missing trailing comma")
+	}
+	return string(buf), nil
+}
+
+// writeNetstring writes s to conn in netstring form.
+func writeNetstring(conn net.Conn, s string) error {
+	_, err := fmt.Fprintf(conn, "%d:%s,", len(s), s)
+	return err
+}
+
+// handleSocketmap serves one request using Postfix's socketmap protocol:
+// a netstring-framed "name key" request, answered with a netstring-framed
+// "OK value" / "NOTFOUND" / "TEMP reason" / "PERM reason" response. This
+// lets srs-resolver be used directly as socketmap:unix:...:srs without a
+// shim, unlike the simple get/200 protocol handle() speaks.
+func handleSocketmap(conn net.Conn, cfg *Config) {
+	appMetrics.ConnOpened()
+	defer appMetrics.ConnClosed()
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(cfg.readTimeout()))
+	conn.SetWriteDeadline(time.Now().Add(cfg.writeTimeout()))
+
+	lr := newLimitedReader(conn, cfg.maxRequestBytes()+netstringFramingOverhead)
+	reader := bufio.NewReader(lr)
+
+	req, err := readNetstring(reader, cfg.maxRequestBytes())
+	if err != nil {
+		log.Error("socketmap read failed", logger.F("error", err))
+		writeSocketmapReadError(conn, err, lr.exceeded)
+		return
+	}
+
+	name, key, found := strings.Cut(req, " ")
+	if !found {
+		log.Error("socketmap invalid request", logger.F("request", req))
+		writeNetstring(conn, "PERM invalid request")
+		return
+	}
+
+	address := strings.TrimSpace(key)
+	remote := conn.RemoteAddr().String()
+	start := time.Now()
+
+	if !strings.HasPrefix(address, "SRS0=") && !strings.HasPrefix(address, "SRS1=") {
+		appMetrics.ObserveDecode(time.Since(start))
+		if isCleanEmail(address) {
+			log.Debug("no decoding required", logger.F("map", name), logger.F("addr", address), logger.F("remote", remote))
+			recordTrace(address, address, nil, time.Since(start))
+			appMetrics.IncResult(metrics.ResultOK)
+			writeNetstring(conn, "OK "+address)
+			return
+		}
+		if cfg.FallbackAddress != "" {
+			log.Error("invalid address, using fallback", logger.F("map", name), logger.F("addr", address), logger.F("remote", remote), logger.F("fallback", cfg.FallbackAddress))
+			recordTrace(address, cfg.FallbackAddress, nil, time.Since(start))
+			appMetrics.IncResult(metrics.ResultFallback)
+			writeNetstring(conn, "OK "+cfg.FallbackAddress)
+		} else {
+			log.Error("invalid address, no fallback configured", logger.F("map", name), logger.F("addr", address), logger.F("remote", remote))
+			recordTrace(address, "", fmt.Errorf("invalid address"), time.Since(start))
+			appMetrics.IncResult(metrics.ResultInvalid)
+			writeNetstring(conn, "NOTFOUND")
+		}
+		return
+	}
+
+	decoded, hops, err := decodeSRS(address, cfg.SrsSecrets, cfg.maxAgeDays())
+	appMetrics.ObserveDecode(time.Since(start))
+	if err != nil {
+		appMetrics.IncResult(classifySRSError(err))
+		if cfg.FallbackAddress != "" {
+			log.Error("invalid SRS, using fallback", logger.F("map", name), logger.F("addr", address), logger.F("remote", remote), logger.F("error", err), logger.F("fallback", cfg.FallbackAddress))
+			recordTrace(address, cfg.FallbackAddress, err, time.Since(start))
+			writeNetstring(conn, "OK "+cfg.FallbackAddress)
+		} else {
+			log.Error("invalid SRS, no fallback configured", logger.F("map", name), logger.F("addr", address), logger.F("remote", remote), logger.F("error", err))
+			recordTrace(address, "", err, time.Since(start))
+			writeNetstring(conn, "NOTFOUND")
+		}
+		return
+	}
+
+	appMetrics.ObserveHops(hops)
+	appMetrics.IncResult(metrics.ResultOK)
+	log.Info("resolved", logger.F("map", name), logger.F("addr", address), logger.F("decoded", decoded), logger.F("remote", remote))
+	recordTrace(address, decoded, nil, time.Since(start))
+	writeNetstring(conn, "OK "+decoded)
+}
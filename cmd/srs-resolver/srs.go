@@ -0,0 +1,197 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sentinel errors so callers (metrics, logging) can classify why an SRS
+// address failed validation without parsing error strings.
+var (
+	ErrSRSMalformed = errors.New("malformed SRS address")
+	ErrSRSBadHMAC   = errors.New("SRS HMAC verification failed")
+	ErrSRSExpired   = errors.New("SRS timestamp expired")
+)
+
+// srsAlphabet is the base32 alphabet SRS implementations (libsrs2, postsrsd)
+// use for both the hash and the timestamp.
+const srsAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+// srsBase32 encodes/decodes the hash portion of an SRS address: RFC 4648
+// base32 without padding, applied to the raw HMAC bytes as a byte stream.
+// The timestamp is a different encoding (see srsTimestamp/srsDecodeTimestamp
+// below): each of its two characters is an independent 5-bit digit, not a
+// byte-grouped base32 stream, so it is NOT decoded via this encoding.
+var srsBase32 = base32.NewEncoding(srsAlphabet).WithPadding(base32.NoPadding)
+
+// srsSecondsPerDay is the SRS timestamp unit: a day counter, not unix time.
+const srsSecondsPerDay = 86400
+
+// srsTimestampModulus is the number of distinct values a 2-char base32
+// timestamp can hold (32^2).
+const srsTimestampModulus = 1024
+
+// srsHash computes the truncated, uppercased base32 HMAC-SHA1 used to
+// authenticate an SRS timestamp+domain+local tuple under secret.
+func srsHash(secret, timestamp, domain, local string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte(domain))
+	mac.Write([]byte(local))
+	sum := srsBase32.EncodeToString(mac.Sum(nil))
+	if len(sum) < 4 {
+		return strings.ToUpper(sum)
+	}
+	return strings.ToUpper(sum[:4])
+}
+
+// srsTimestamp encodes the current day count as the 2-char base32 string
+// SRS embeds in new addresses. Unlike srsBase32's byte-stream encoding,
+// SRS's timestamp packs the 10-bit day counter as two independent 5-bit
+// digits, one per character: this is the encoding libsrs2/postsrsd use.
+func srsTimestamp(now time.Time) string {
+	day := int(now.Unix()/srsSecondsPerDay) % srsTimestampModulus
+	return string([]byte{srsAlphabet[(day>>5)&0x1f], srsAlphabet[day&0x1f]})
+}
+
+// srsDecodeTimestamp turns a 2-char base32 timestamp back into its day
+// counter mod srsTimestampModulus, reversing srsTimestamp's 5-bit-per-char
+// packing.
+func srsDecodeTimestamp(ts string) (int, error) {
+	if len(ts) != 2 {
+		return 0, fmt.Errorf("bad timestamp length")
+	}
+	upper := strings.ToUpper(ts)
+	hi := strings.IndexByte(srsAlphabet, upper[0])
+	lo := strings.IndexByte(srsAlphabet, upper[1])
+	if hi < 0 || lo < 0 {
+		return 0, fmt.Errorf("bad timestamp encoding")
+	}
+	return ((hi << 5) | lo) % srsTimestampModulus, nil
+}
+
+// srsAgeOK reports whether a decoded day counter is within maxAgeDays of
+// today, accounting for the modulus wraparound.
+func srsAgeOK(decoded int, now time.Time, maxAgeDays int) bool {
+	today := int(now.Unix()/srsSecondsPerDay) % srsTimestampModulus
+	age := (today - decoded + srsTimestampModulus) % srsTimestampModulus
+	return age <= maxAgeDays
+}
+
+// verifyHMAC tries every configured secret and reports whether any of them
+// reproduces the hash, so secret rotation (old secret still verifies,
+// new secret signs) keeps working.
+func verifyHMAC(secrets []string, want, timestamp, domain, local string) bool {
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(srsHash(secret, timestamp, domain, local)), []byte(strings.ToUpper(want))) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAndDecodeSRS0 verifies the HMAC and timestamp window of an
+// "SRS0=HHHH=TT=domain=local[@forwarder]" address (the "SRS0=" prefix must
+// already be stripped) and returns the original local@domain address on
+// success.
+func validateAndDecodeSRS0(rest string, secrets []string, maxAgeDays int) (string, error) {
+	parts := strings.SplitN(rest, "=", 4)
+	if len(parts) != 4 {
+		return "", fmt.Errorf("%w: SRS0 wrong number of parts", ErrSRSMalformed)
+	}
+	hash, timestamp, domain, local := parts[0], parts[1], parts[2], parts[3]
+	// The "@forwarder" host of the rewritten address is not part of the
+	// signed local part.
+	local, _, _ = strings.Cut(local, "@")
+
+	if !verifyHMAC(secrets, hash, timestamp, domain, local) {
+		return "", ErrSRSBadHMAC
+	}
+
+	day, err := srsDecodeTimestamp(timestamp)
+	if err != nil {
+		return "", fmt.Errorf("%w: SRS0 timestamp: %v", ErrSRSMalformed, err)
+	}
+	if !srsAgeOK(day, time.Now(), maxAgeDays) {
+		return "", ErrSRSExpired
+	}
+
+	return fmt.Sprintf("%s@%s", local, domain), nil
+}
+
+// validateAndDecodeSRS1 unwinds one or more nested
+// "SRS1=HHHH=hop_domain==..." layers: each layer's outer hop hash is
+// verified, then the address it wraps is unwound in turn, until the
+// innermost SRS0 address is reached and revalidated. It returns the
+// decoded address and the number of SRS1 hops that were unwound.
+func validateAndDecodeSRS1(rest string, secrets []string, maxAgeDays int) (string, int, error) {
+	hops := 0
+	for {
+		outer, inner, ok := strings.Cut(rest, "==")
+		if !ok {
+			return "", hops, fmt.Errorf("%w: SRS1 missing inner address", ErrSRSMalformed)
+		}
+
+		outerParts := strings.SplitN(outer, "=", 2)
+		if len(outerParts) != 2 {
+			return "", hops, fmt.Errorf("%w: SRS1 wrong number of outer parts", ErrSRSMalformed)
+		}
+		hash, hopDomain := outerParts[0], outerParts[1]
+
+		if !verifyHMAC(secrets, hash, "", hopDomain, "") {
+			return "", hops, ErrSRSBadHMAC
+		}
+		hops++
+
+		switch {
+		case strings.HasPrefix(inner, "SRS0="):
+			decoded, err := validateAndDecodeSRS0(strings.TrimPrefix(inner, "SRS0="), secrets, maxAgeDays)
+			return decoded, hops, err
+		case strings.HasPrefix(inner, "SRS1="):
+			rest = strings.TrimPrefix(inner, "SRS1=")
+		default:
+			return "", hops, fmt.Errorf("%w: SRS1 inner address is not SRS0 or SRS1", ErrSRSMalformed)
+		}
+	}
+}
+
+// decodeSRS validates and decodes an SRS0 or SRS1 address, recomputing its
+// HMAC against the configured secrets and checking its timestamp window.
+// It replaces the prior implementation, which trusted the address without
+// verifying either. The returned hop count is the number of SRS1 layers
+// unwound (0 for a bare SRS0 address).
+func decodeSRS(srs string, secrets []string, maxAgeDays int) (decoded string, hops int, err error) {
+	switch {
+	case strings.HasPrefix(srs, "SRS0="):
+		decoded, err = validateAndDecodeSRS0(strings.TrimPrefix(srs, "SRS0="), secrets, maxAgeDays)
+		return decoded, 0, err
+	case strings.HasPrefix(srs, "SRS1="):
+		return validateAndDecodeSRS1(strings.TrimPrefix(srs, "SRS1="), secrets, maxAgeDays)
+	default:
+		return "", 0, fmt.Errorf("%w: not an SRS address", ErrSRSMalformed)
+	}
+}
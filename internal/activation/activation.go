@@ -0,0 +1,67 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package activation adopts listeners passed in by systemd socket
+// activation (LISTEN_FDS/LISTEN_PID, starting at fd 3), per
+// sd_listen_fds(3). It lets a unit bind privileged ports with
+// Accept=no sockets and restart the resolver without dropping
+// in-flight connections.
+package activation
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFdsStart is the first file descriptor systemd hands over;
+// 0, 1, 2 remain stdin/stdout/stderr.
+const listenFdsStart = 3
+
+// Listeners returns the listeners passed in by systemd, or nil if this
+// process wasn't socket-activated. On success it unsets LISTEN_PID and
+// LISTEN_FDS so a child process doesn't also try to adopt them.
+func Listeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+
+	listeners := make([]net.Listener, 0, nfds)
+	for i := 0; i < nfds; i++ {
+		fd := listenFdsStart + i
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("listen_fd_%d", fd))
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("activation: adopt fd %d: %v", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	return listeners, nil
+}
@@ -0,0 +1,62 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package logger
+
+import (
+	"log"
+	"os"
+)
+
+// fileSink is the original behavior: plain timestamped lines written to a
+// file (or stderr when no path is configured).
+type fileSink struct {
+	out *log.Logger
+	f   *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	var f *os.File
+	out := os.Stderr
+	if path != "" {
+		var err error
+		f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		out = f
+	}
+	return &fileSink{out: log.New(out, "", log.LstdFlags), f: f}, nil
+}
+
+func (s *fileSink) Write(level Level, msg string, fields []Field) error {
+	line := msg
+	if formatted := formatFields(fields); formatted != "" {
+		line = msg + " " + formatted
+	}
+	s.out.Printf("[%s] %s", levelTag(level), line)
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	if s.f != nil {
+		return s.f.Close()
+	}
+	return nil
+}
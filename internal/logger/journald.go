@@ -0,0 +1,113 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// journaldSocket is the well-known datagram socket the systemd journal
+// listens on for native structured log entries.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldSink writes native journal entries (PRIORITY=, MESSAGE=, plus
+// one field per log Field) to the journal's datagram socket, so they show
+// up with structured fields in `journalctl`.
+type journaldSink struct {
+	conn net.Conn
+}
+
+func newJournaldSink() (*journaldSink, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("logger: dial journald socket: %v", err)
+	}
+	return &journaldSink{conn: conn}, nil
+}
+
+// journalPriority maps our Level to the syslog(3) severity journald
+// expects in PRIORITY (0=emerg .. 7=debug).
+func journalPriority(level Level) int {
+	switch level {
+	case LevelError:
+		return 3 // LOG_ERR
+	case LevelInfo:
+		return 6 // LOG_INFO
+	default:
+		return 7 // LOG_DEBUG
+	}
+}
+
+// sanitizeJournalKey turns an arbitrary field key into a valid journald
+// field name: uppercase ASCII letters, digits and underscores, not
+// starting with a digit.
+func sanitizeJournalKey(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	name := b.String()
+	if name == "" {
+		return "FIELD"
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "FIELD_" + name
+	}
+	return name
+}
+
+// appendJournalField appends one KEY=VALUE entry in the journal export
+// format, using the binary length-prefixed form when the value contains a
+// newline.
+func appendJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", key, value)
+		return
+	}
+	buf.WriteString(key)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func (s *journaldSink) Write(level Level, msg string, fields []Field) error {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "PRIORITY", fmt.Sprintf("%d", journalPriority(level)))
+	appendJournalField(&buf, "MESSAGE", msg)
+	for _, f := range fields {
+		appendJournalField(&buf, sanitizeJournalKey(f.Key), fmt.Sprintf("%v", f.Value))
+	}
+	_, err := s.conn.Write(buf.Bytes())
+	return err
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,177 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package logger is a small structured, leveled logging subsystem for
+// srs-resolver. It replaces ad-hoc fmt.Sprintf log lines with key-value
+// pairs (addr=, remote=, decoded=, ...) and can fan those pairs out to a
+// plain log file, local syslog, or the systemd journal.
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Level is a log verbosity level, ordered least to most verbose.
+type Level int
+
+const (
+	LevelError Level = iota
+	LevelInfo
+	LevelDebug
+)
+
+// ParseLevel maps a config string ("error", "info", "debug") to a Level,
+// defaulting to LevelError for anything else.
+func ParseLevel(s string) Level {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug
+	case "info":
+		return LevelInfo
+	default:
+		return LevelError
+	}
+}
+
+// Field is one key-value pair attached to a log line.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F builds a Field; the common way to pass context to a log call, e.g.
+// log.Info("resolved", logger.F("addr", address), logger.F("decoded", decoded)).
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// sink is the thing that actually delivers a log line somewhere: a file,
+// syslog, or the journal.
+type sink interface {
+	Write(level Level, msg string, fields []Field) error
+	Close() error
+}
+
+// Config selects and configures the logging backend.
+type Config struct {
+	// Backend is "file" (default), "syslog", or "journald".
+	Backend string
+	// FilePath is the destination for the "file" backend. Empty means
+	// stderr.
+	FilePath string
+	// Facility is the syslog facility to log under, e.g. "mail". Only
+	// used by the "syslog" backend.
+	Facility string
+	Level    Level
+}
+
+// Logger is a leveled, structured logger backed by a single sink.
+type Logger struct {
+	level Level
+	sink  sink
+}
+
+// New builds a Logger for the given Config. The zero Config yields a
+// Logger that writes plain lines to stderr at error level.
+func New(cfg Config) (*Logger, error) {
+	var s sink
+	var err error
+
+	switch strings.ToLower(cfg.Backend) {
+	case "syslog":
+		s, err = newSyslogSink(cfg.Facility)
+	case "journald":
+		s, err = newJournaldSink()
+	case "file", "":
+		s, err = newFileSink(cfg.FilePath)
+	default:
+		return nil, fmt.Errorf("logger: unknown log_backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Logger{level: cfg.Level, sink: s}, nil
+}
+
+// Close releases any resources (file handles, sockets) held by the sink.
+func (l *Logger) Close() error {
+	if l == nil || l.sink == nil {
+		return nil
+	}
+	return l.sink.Close()
+}
+
+func (l *Logger) log(level Level, msg string, fields []Field) {
+	if l == nil || l.sink == nil || level > l.level {
+		return
+	}
+	if err := l.sink.Write(level, msg, fields); err != nil {
+		fmt.Fprintf(os.Stderr, "logger: write failed: %v\n", err)
+	}
+}
+
+// Error logs an error-level line. Error-level lines are always emitted,
+// regardless of the configured level.
+func (l *Logger) Error(msg string, fields ...Field) { l.log(LevelError, msg, fields) }
+
+// Info logs an info-level line.
+func (l *Logger) Info(msg string, fields ...Field) { l.log(LevelInfo, msg, fields) }
+
+// Debug logs a debug-level line.
+func (l *Logger) Debug(msg string, fields ...Field) { l.log(LevelDebug, msg, fields) }
+
+// Fatal logs an error-level line and then terminates the process, mirroring
+// log.Fatalf.
+func (l *Logger) Fatal(msg string, fields ...Field) {
+	l.log(LevelError, msg, fields)
+	os.Exit(1)
+}
+
+// formatFields renders fields as "key=value key2=value2", quoting values
+// that contain whitespace so lines stay single-line and grep/awk-friendly.
+func formatFields(fields []Field) string {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		val := fmt.Sprintf("%v", f.Value)
+		if strings.ContainsAny(val, " \t\r\n\"") {
+			val = fmt.Sprintf("%q", val)
+		}
+		fmt.Fprintf(&b, "%s=%s", f.Key, val)
+	}
+	return b.String()
+}
+
+func levelTag(level Level) string {
+	switch level {
+	case LevelError:
+		return "ERROR"
+	case LevelInfo:
+		return "INFO"
+	case LevelDebug:
+		return "DEBUG"
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,92 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package logger
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// syslogSink writes through the local syslog daemon under a configurable
+// facility. "mail" is the natural facility for an MTA helper like this
+// one; "daemon" and "local0" through "local7" are also accepted, and any
+// other facility name is rejected.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func facilityFromString(name string) (syslog.Priority, error) {
+	switch strings.ToLower(name) {
+	case "", "mail":
+		return syslog.LOG_MAIL, nil
+	case "daemon":
+		return syslog.LOG_DAEMON, nil
+	case "local0":
+		return syslog.LOG_LOCAL0, nil
+	case "local1":
+		return syslog.LOG_LOCAL1, nil
+	case "local2":
+		return syslog.LOG_LOCAL2, nil
+	case "local3":
+		return syslog.LOG_LOCAL3, nil
+	case "local4":
+		return syslog.LOG_LOCAL4, nil
+	case "local5":
+		return syslog.LOG_LOCAL5, nil
+	case "local6":
+		return syslog.LOG_LOCAL6, nil
+	case "local7":
+		return syslog.LOG_LOCAL7, nil
+	default:
+		return 0, fmt.Errorf("logger: unknown syslog facility %q", name)
+	}
+}
+
+func newSyslogSink(facility string) (*syslogSink, error) {
+	priority, err := facilityFromString(facility)
+	if err != nil {
+		return nil, err
+	}
+	w, err := syslog.New(priority|syslog.LOG_INFO, "srs-resolver")
+	if err != nil {
+		return nil, fmt.Errorf("logger: dial syslog: %v", err)
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(level Level, msg string, fields []Field) error {
+	line := msg
+	if formatted := formatFields(fields); formatted != "" {
+		line = msg + " " + formatted
+	}
+	switch level {
+	case LevelError:
+		return s.w.Err(line)
+	case LevelInfo:
+		return s.w.Info(line)
+	default:
+		return s.w.Debug(line)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,96 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package metrics exposes srs-resolver's Prometheus metrics: how
+// requests were resolved, how long decoding took, how many connections
+// are active, and how deep SRS1 unwrapping went.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Result labels the outcome of resolving one request, mirroring the
+// branches in handle()/handleSocketmap().
+type Result string
+
+const (
+	ResultOK         Result = "ok"
+	ResultFallback   Result = "fallback"
+	ResultInvalid    Result = "invalid"
+	ResultSRSBadHMAC Result = "srs_bad_hmac"
+	ResultSRSExpired Result = "srs_expired"
+)
+
+// Metrics holds the process's Prometheus collectors.
+type Metrics struct {
+	requestsTotal     *prometheus.CounterVec
+	decodeSeconds     prometheus.Histogram
+	activeConnections prometheus.Gauge
+	srsHops           prometheus.Histogram
+}
+
+// New creates and registers the collectors against reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "srs_resolver_requests_total",
+			Help: "Total requests handled, labeled by how they were resolved.",
+		}, []string{"result"}),
+		decodeSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "srs_resolver_decode_seconds",
+			Help:    "Time spent decoding a single address.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		activeConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "srs_resolver_active_connections",
+			Help: "Number of connections currently being handled.",
+		}),
+		srsHops: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "srs_resolver_srs_hops",
+			Help:    "Number of SRS1 hops unwound while decoding an address.",
+			Buckets: []float64{0, 1, 2, 3, 4, 5},
+		}),
+	}
+	reg.MustRegister(m.requestsTotal, m.decodeSeconds, m.activeConnections, m.srsHops)
+	return m
+}
+
+// IncResult records one completed request with the given result.
+func (m *Metrics) IncResult(result Result) {
+	m.requestsTotal.WithLabelValues(string(result)).Inc()
+}
+
+// ObserveDecode records how long a decodeSRS call took. For an address
+// that is not SRS it is recorded before the clean-email check runs.
+func (m *Metrics) ObserveDecode(d time.Duration) {
+	m.decodeSeconds.Observe(d.Seconds())
+}
+
+// ObserveHops records the SRS1 unwrapping depth of a decoded address.
+func (m *Metrics) ObserveHops(hops int) {
+	m.srsHops.Observe(float64(hops))
+}
+
+// ConnOpened/ConnClosed track the in-flight connection gauge around a
+// handle()/handleSocketmap() call.
+func (m *Metrics) ConnOpened() { m.activeConnections.Inc() }
+func (m *Metrics) ConnClosed() { m.activeConnections.Dec() }
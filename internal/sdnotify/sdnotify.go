@@ -0,0 +1,74 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package sdnotify implements the small subset of the systemd sd_notify
+// protocol srs-resolver needs: reporting readiness and, when running
+// under a unit with WatchdogSec= set, periodic keepalive pings.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notify sends a state string (e.g. "READY=1", "WATCHDOG=1", "STOPPING=1")
+// to the socket named by $NOTIFY_SOCKET. It is a no-op, returning nil, when
+// the process wasn't started by systemd with notification enabled.
+func Notify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	// systemd commonly hands out an abstract-namespace socket, written
+	// as a leading "@"; Go's net package expects that as a leading NUL
+	// byte instead, same as every other sd_notify client.
+	if strings.HasPrefix(addr, "@") {
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.Dial("unixgram", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval reports the interval at which we must call
+// Notify("WATCHDOG=1") to keep systemd from considering the service
+// hung, derived from $WATCHDOG_USEC. It returns ok=false when no
+// watchdog is configured.
+func WatchdogInterval() (interval time.Duration, ok bool) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	// Ping at half the timeout, as systemd's own docs recommend, so a
+	// missed tick or two doesn't immediately trip the watchdog.
+	return time.Duration(n) * time.Microsecond / 2, true
+}
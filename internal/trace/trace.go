@@ -0,0 +1,78 @@
+/*
+srs-resolver - SRS decoder for autoresponders
+Copyright (C) 2025 Damian Szlage / Umbrella Dev Systems / DriftZone.pl
+https://github.com/dszlage/srs-resolver
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package trace is a small in-memory ring buffer of the last N requests
+// srs-resolver handled, so operators can inspect misrouted mail via
+// /debug/traces without turning on debug logging globally.
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry records the inputs, outputs, and timing of one resolved request.
+type Entry struct {
+	Time     time.Time     `json:"time"`
+	Input    string        `json:"input"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Ring is a fixed-size, concurrency-safe ring buffer of Entry.
+type Ring struct {
+	mu     sync.Mutex
+	buf    []Entry
+	next   int
+	filled bool
+}
+
+// NewRing creates a Ring holding up to size entries.
+func NewRing(size int) *Ring {
+	return &Ring{buf: make([]Entry, size)}
+}
+
+// Add records e, overwriting the oldest entry once the ring is full.
+func (r *Ring) Add(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = e
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.filled = true
+	}
+}
+
+// Snapshot returns the recorded entries, oldest first.
+func (r *Ring) Snapshot() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.filled {
+		out := make([]Entry, r.next)
+		copy(out, r.buf[:r.next])
+		return out
+	}
+
+	out := make([]Entry, len(r.buf))
+	copy(out, r.buf[r.next:])
+	copy(out[len(r.buf)-r.next:], r.buf[:r.next])
+	return out
+}